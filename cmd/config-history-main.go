@@ -0,0 +1,329 @@
+/*
+ * Minio Client (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/minio/cli"
+	"github.com/minio/mc/pkg/console"
+	"github.com/minio/minio/pkg/probe"
+)
+
+// configHistoryIDRegexp matches the id format snapshotConfigHistory
+// generates ('20060102T150405Z', optionally suffixed '-NNNN' to
+// disambiguate multiple snapshots within the same second). Restoring
+// only ever accepts an id in this shape, so it can't be used to read (and
+// then restore from) an arbitrary file outside the history directory.
+var configHistoryIDRegexp = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}Z(-[0-9]{4})?$`)
+
+// isValidConfigHistoryID - true if 'id' has the shape snapshotConfigHistory
+// generates.
+func isValidConfigHistoryID(id string) bool {
+	return configHistoryIDRegexp.MatchString(id)
+}
+
+// defaultMaxConfigHistory is the number of snapshots retained by default
+// before the oldest ones are pruned.
+const defaultMaxConfigHistory = 10
+
+// maxConfigHistory is the effective ring size, overridable with
+// '--max-history' on 'config host' mutating operations.
+var maxConfigHistory = defaultMaxConfigHistory
+
+var configHistoryCmd = cli.Command{
+	Name:   "history",
+	Usage:  "List, restore and clear mc config snapshots.",
+	Action: mainConfigHistory,
+	Flags:  append([]cli.Flag{}, globalFlags...),
+	CustomHelpTemplate: `NAME:
+   mc config host {{.Name}} - {{.Usage}}
+
+USAGE:
+   mc config host {{.Name}} OPERATION
+
+OPERATION:
+   list          list all recorded config snapshots.
+   restore ID    roll back the config file to a prior snapshot.
+   clear         purge all recorded config snapshots.
+
+FLAGS:
+  {{range .Flags}}{{.}}
+  {{end}}
+EXAMPLES:
+   1. List all config snapshots.
+      $ mc config host {{.Name}} list
+
+   2. Restore the config file to snapshot '20160223T120000Z-0001'.
+      $ mc config host {{.Name}} restore 20160223T120000Z-0001
+
+   3. Clear all config snapshots.
+      $ mc config host {{.Name}} clear
+`,
+}
+
+// historyMessage container for content message structure
+type historyMessage struct {
+	op        string
+	Status    string `json:"status"`
+	ID        string `json:"id,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Operation string `json:"operation,omitempty"`
+	Alias     string `json:"alias,omitempty"`
+}
+
+// String colorized history message
+func (h historyMessage) String() string {
+	switch h.op {
+	case "list":
+		message := console.Colorize("HistoryID", fmt.Sprintf("%s: ", h.ID))
+		message += console.Colorize("HistoryTimestamp", fmt.Sprintf("%-25.25s", h.Timestamp))
+		message += console.Colorize("HistoryOperation", fmt.Sprintf("  %-8.8s", h.Operation))
+		message += console.Colorize("HistoryAlias", fmt.Sprintf("  %s", h.Alias))
+		return message
+	case "restore":
+		return console.Colorize("HistoryMessage", "Restored config to snapshot ‘"+h.ID+"’ successfully.")
+	case "clear":
+		return console.Colorize("HistoryMessage", "Cleared all config snapshots successfully.")
+	default:
+		return ""
+	}
+}
+
+// JSON jsonified history message
+func (h historyMessage) JSON() string {
+	h.Status = "success"
+	jsonMessageBytes, e := json.Marshal(h)
+	fatalIf(probe.NewError(e), "Unable to marshal into JSON.")
+
+	return string(jsonMessageBytes)
+}
+
+// checkConfigHistorySyntax - verifies input arguments to 'config history'.
+func checkConfigHistorySyntax(ctx *cli.Context) {
+	if !ctx.Args().Present() {
+		cli.ShowCommandHelpAndExit(ctx, "history", 1) // last argument is exit code
+	}
+
+	switch strings.TrimSpace(ctx.Args().First()) {
+	case "list", "clear":
+	case "restore":
+		if len(ctx.Args().Tail()) != 1 {
+			fatalIf(errInvalidArgument().Trace(ctx.Args().Tail()...),
+				"Incorrect number of arguments for history restore command.")
+		}
+		if id := ctx.Args().Tail().Get(0); !isValidConfigHistoryID(id) {
+			fatalIf(errInvalidArgument().Trace(id), "Invalid config snapshot id ‘"+id+"’.")
+		}
+	default:
+		cli.ShowCommandHelpAndExit(ctx, "history", 1) // last argument is exit code
+	}
+}
+
+func mainConfigHistory(ctx *cli.Context) error {
+	// Set global flags from context.
+	setGlobalsFromContext(ctx)
+
+	// check 'config history' cli arguments.
+	checkConfigHistorySyntax(ctx)
+
+	console.SetColor("HistoryMessage", color.New(color.FgGreen))
+	console.SetColor("HistoryID", color.New(color.FgCyan, color.Bold))
+	console.SetColor("HistoryTimestamp", color.New(color.FgCyan))
+	console.SetColor("HistoryOperation", color.New(color.FgYellow))
+	console.SetColor("HistoryAlias", color.New(color.FgBlue))
+
+	cmd := ctx.Args().First()
+	args := ctx.Args().Tail()
+
+	switch strings.TrimSpace(cmd) {
+	case "list":
+		listConfigHistory()
+	case "restore":
+		restoreConfigHistory(args.Get(0))
+	case "clear":
+		clearConfigHistory()
+	}
+	return nil
+}
+
+// configSnapshot is a single point-in-time copy of the mc config file,
+// recorded right before a mutating 'config host' operation is committed.
+type configSnapshot struct {
+	ID        string     `json:"id"`
+	Timestamp time.Time  `json:"timestamp"`
+	Operation string     `json:"operation"`
+	Alias     string     `json:"alias"`
+	Config    mcConfigV8 `json:"config"`
+}
+
+// mustGetMcConfigHistoryDir - returns the directory snapshots are stored in,
+// creating it if it does not yet exist.
+func mustGetMcConfigHistoryDir() string {
+	historyDir := filepath.Join(mustGetMcConfigDir(), "history")
+	fatalIf(probe.NewError(os.MkdirAll(historyDir, 0700)), "Unable to create config history directory.")
+	return historyDir
+}
+
+// snapshotConfigHistory - records 'cfg' (the config as it was *before* the
+// caller's mutation, except for 'lock', which passes the already-encrypted
+// config) as a new history entry and prunes the ring down to
+// 'maxConfigHistory' entries.
+func snapshotConfigHistory(operation, alias string, cfg mcConfigV8) *probe.Error {
+	historyDir := mustGetMcConfigHistoryDir()
+
+	now := time.Now().UTC()
+	id := now.Format("20060102T150405Z")
+	// Disambiguate multiple snapshots within the same second.
+	for seq := 0; ; seq++ {
+		candidate := id
+		if seq > 0 {
+			candidate = fmt.Sprintf("%s-%04d", id, seq)
+		}
+		if _, e := os.Stat(filepath.Join(historyDir, candidate+".json")); os.IsNotExist(e) {
+			id = candidate
+			break
+		}
+	}
+
+	snap := configSnapshot{
+		ID:        id,
+		Timestamp: now,
+		Operation: operation,
+		Alias:     alias,
+		Config:    cfg,
+	}
+
+	data, e := json.Marshal(snap)
+	if e != nil {
+		return probe.NewError(e)
+	}
+
+	snapshotPath := filepath.Join(historyDir, id+".json")
+	if e = ioutil.WriteFile(snapshotPath, data, 0600); e != nil {
+		return probe.NewError(e)
+	}
+
+	return pruneConfigHistory(historyDir)
+}
+
+// pruneConfigHistory - removes the oldest snapshots once the ring exceeds
+// 'maxConfigHistory' entries.
+func pruneConfigHistory(historyDir string) *probe.Error {
+	snapshots, err := readConfigHistorySnapshots(historyDir)
+	if err != nil {
+		return err.Trace(historyDir)
+	}
+
+	if maxConfigHistory <= 0 || len(snapshots) <= maxConfigHistory {
+		return nil
+	}
+
+	for _, snap := range snapshots[:len(snapshots)-maxConfigHistory] {
+		if e := os.Remove(filepath.Join(historyDir, snap.ID+".json")); e != nil && !os.IsNotExist(e) {
+			return probe.NewError(e)
+		}
+	}
+	return nil
+}
+
+// readConfigHistorySnapshots - loads all recorded snapshots, oldest first.
+func readConfigHistorySnapshots(historyDir string) ([]configSnapshot, *probe.Error) {
+	entries, e := ioutil.ReadDir(historyDir)
+	if e != nil {
+		return nil, probe.NewError(e)
+	}
+
+	var snapshots []configSnapshot
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, e := ioutil.ReadFile(filepath.Join(historyDir, entry.Name()))
+		if e != nil {
+			return nil, probe.NewError(e)
+		}
+		var snap configSnapshot
+		if e = json.Unmarshal(data, &snap); e != nil {
+			return nil, probe.NewError(e)
+		}
+		snapshots = append(snapshots, snap)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Timestamp.Before(snapshots[j].Timestamp)
+	})
+	return snapshots, nil
+}
+
+// listConfigHistory - prints all recorded snapshots, oldest first.
+func listConfigHistory() {
+	historyDir := mustGetMcConfigHistoryDir()
+	snapshots, err := readConfigHistorySnapshots(historyDir)
+	fatalIf(err.Trace(historyDir), "Unable to list config history.")
+
+	for _, snap := range snapshots {
+		printMsg(historyMessage{
+			op:        "list",
+			ID:        snap.ID,
+			Timestamp: snap.Timestamp.Format(time.RFC3339),
+			Operation: snap.Operation,
+			Alias:     snap.Alias,
+		})
+	}
+}
+
+// restoreConfigHistory - atomically rolls the mc config file back to the
+// snapshot identified by 'id'.
+func restoreConfigHistory(id string) {
+	historyDir := mustGetMcConfigHistoryDir()
+	snapshotPath := filepath.Join(historyDir, id+".json")
+
+	data, e := ioutil.ReadFile(snapshotPath)
+	fatalIf(probe.NewError(e), "Unable to read config snapshot ‘"+id+"’.")
+
+	var snap configSnapshot
+	fatalIf(probe.NewError(json.Unmarshal(data, &snap)), "Unable to parse config snapshot ‘"+id+"’.")
+
+	err := saveMcConfig(&snap.Config)
+	fatalIf(err.Trace(id), "Unable to restore config snapshot ‘"+id+"’.")
+
+	printMsg(historyMessage{op: "restore", ID: id})
+}
+
+// clearConfigHistory - purges all recorded snapshots.
+func clearConfigHistory() {
+	historyDir := mustGetMcConfigHistoryDir()
+	snapshots, err := readConfigHistorySnapshots(historyDir)
+	fatalIf(err.Trace(historyDir), "Unable to clear config history.")
+
+	for _, snap := range snapshots {
+		e := os.Remove(filepath.Join(historyDir, snap.ID+".json"))
+		fatalIf(probe.NewError(e), "Unable to remove config snapshot ‘"+snap.ID+"’.")
+	}
+
+	printMsg(historyMessage{op: "clear"})
+}
@@ -0,0 +1,320 @@
+/*
+ * Minio Client (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/minio/cli"
+	"github.com/minio/minio/pkg/probe"
+	"gopkg.in/yaml.v2"
+)
+
+// hostBatchEntry is one host entry out of a '--from-file' batch or a
+// multi-line stdin batch. 'Op' defaults to the invoked subcommand (add,
+// remove or set) but a '--from-file' entry may override it to mix
+// operations in a single batch.
+type hostBatchEntry struct {
+	Op        string `json:"op,omitempty" yaml:"op,omitempty"`
+	Alias     string `json:"alias" yaml:"alias"`
+	URL       string `json:"url,omitempty" yaml:"url,omitempty"`
+	AccessKey string `json:"accessKey,omitempty" yaml:"accessKey,omitempty"`
+	SecretKey string `json:"secretKey,omitempty" yaml:"secretKey,omitempty"`
+	API       string `json:"api,omitempty" yaml:"api,omitempty"`
+}
+
+// isStdinPiped - true when stdin is redirected or piped rather than an
+// interactive terminal, the signal we use to switch 'add'/'remove'/'set'
+// into multi-line batch mode when no positional alias is given.
+func isStdinPiped() bool {
+	info, e := os.Stdin.Stat()
+	if e != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
+// readHostBatchFile - loads a '--from-file' batch, auto-detecting YAML vs
+// JSON from the file extension.
+func readHostBatchFile(path string) ([]hostBatchEntry, *probe.Error) {
+	data, e := ioutil.ReadFile(path)
+	if e != nil {
+		return nil, probe.NewError(e)
+	}
+
+	var entries []hostBatchEntry
+	if strings.HasSuffix(path, ".json") {
+		e = json.Unmarshal(data, &entries)
+	} else {
+		e = yaml.Unmarshal(data, &entries)
+	}
+	if e != nil {
+		return nil, probe.NewError(e)
+	}
+	return entries, nil
+}
+
+// readHostBatchStdin - parses multi-line stdin of the form
+// 'alias=url,accessKey=...,secretKey=...,api=...', one host per line.
+func readHostBatchStdin() ([]hostBatchEntry, *probe.Error) {
+	var entries []hostBatchEntry
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var entry hostBatchEntry
+		for i, field := range strings.Split(line, ",") {
+			kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+			if len(kv) != 2 {
+				return nil, errInvalidArgument().Trace(line)
+			}
+			key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+			switch key {
+			case "alias":
+				entry.Alias = value
+			case "url":
+				entry.URL = value
+			case "accessKey":
+				entry.AccessKey = value
+			case "secretKey":
+				entry.SecretKey = value
+			case "api":
+				entry.API = value
+			default:
+				// The first field may be given as '<alias>=<url>'.
+				if i == 0 {
+					entry.Alias, entry.URL = key, value
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+	if e := scanner.Err(); e != nil {
+		return nil, probe.NewError(e)
+	}
+	return entries, nil
+}
+
+// collectHostBatch - resolves the batch of entries for 'op' from either
+// '--from-file' or piped stdin.
+func collectHostBatch(ctx *cli.Context, op string) []hostBatchEntry {
+	var (
+		entries []hostBatchEntry
+		err     *probe.Error
+	)
+
+	if fromFile := ctx.String("from-file"); fromFile != "" {
+		entries, err = readHostBatchFile(fromFile)
+		fatalIf(err.Trace(fromFile), "Unable to read host batch ‘"+fromFile+"’.")
+	} else {
+		entries, err = readHostBatchStdin()
+		fatalIf(err.Trace(), "Unable to read host batch from stdin.")
+	}
+
+	for i := range entries {
+		if entries[i].Op == "" {
+			entries[i].Op = op
+		}
+	}
+	return entries
+}
+
+// validateHostBatch - validates every entry up front, against both the
+// entry's own fields and the live config's existing hosts, so a batch
+// either commits in full or not at all.
+func validateHostBatch(entries []hostBatchEntry, conf *mcConfigV8) {
+	for _, entry := range entries {
+		if !isValidAlias(entry.Alias) {
+			fatalIf(errDummy().Trace(entry.Alias), "Invalid alias ‘"+entry.Alias+"’.")
+		}
+
+		switch entry.Op {
+		case "add":
+			if !isValidHostURL(entry.URL) {
+				fatalIf(errDummy().Trace(entry.URL), "Invalid URL ‘"+entry.URL+"’.")
+			}
+			if !isValidAccessKey(entry.AccessKey) {
+				fatalIf(errInvalidArgument().Trace(entry.AccessKey), "Invalid access key ‘"+entry.AccessKey+"’.")
+			}
+			if !isValidSecretKey(entry.SecretKey) {
+				fatalIf(errInvalidArgument().Trace(entry.SecretKey), "Invalid secret key ‘"+entry.SecretKey+"’.")
+			}
+			if entry.API != "" && !isValidAPI(entry.API) {
+				fatalIf(errInvalidArgument().Trace(entry.API), "Unrecognized API signature. Valid options are ‘[S3v4, S3v2]’.")
+			}
+		case "remove":
+			// Alias was already validated above; nothing else to check.
+		case "set":
+			if _, ok := conf.Hosts[entry.Alias]; !ok {
+				fatalIf(errDummy().Trace(entry.Alias), "No such host ‘"+entry.Alias+"’.")
+			}
+			if entry.URL == "" && entry.AccessKey == "" && entry.SecretKey == "" && entry.API == "" {
+				fatalIf(errInvalidArgument().Trace(entry.Alias), "No fields to update for ‘"+entry.Alias+"’.")
+			}
+			if entry.URL != "" && !isValidHostURL(entry.URL) {
+				fatalIf(errDummy().Trace(entry.URL), "Invalid URL ‘"+entry.URL+"’.")
+			}
+			if entry.AccessKey != "" && !isValidAccessKey(entry.AccessKey) {
+				fatalIf(errInvalidArgument().Trace(entry.AccessKey), "Invalid access key ‘"+entry.AccessKey+"’.")
+			}
+			if entry.SecretKey != "" && !isValidSecretKey(entry.SecretKey) {
+				fatalIf(errInvalidArgument().Trace(entry.SecretKey), "Invalid secret key ‘"+entry.SecretKey+"’.")
+			}
+			if entry.API != "" && !isValidAPI(entry.API) {
+				fatalIf(errInvalidArgument().Trace(entry.API), "Unrecognized API signature. Valid options are ‘[S3v4, S3v2]’.")
+			}
+		default:
+			fatalIf(errInvalidArgument().Trace(entry.Op), "Unrecognized batch operation ‘"+entry.Op+"’.")
+		}
+	}
+}
+
+// applyHostBatch - validates the full batch, then commits every entry under
+// a single saveMcConfig call: all entries land, or none do.
+func applyHostBatch(ctx *cli.Context, op string) {
+	entries := collectHostBatch(ctx, op)
+
+	conf, err := loadMcConfig()
+	fatalIf(err.Trace(globalMCConfigVersion), "Unable to load config ‘"+mustGetMcConfigPath()+"’.")
+
+	validateHostBatch(entries, conf)
+
+	fatalIf(snapshotConfigHistory(op+"-batch", "*", *conf).Trace(), "Unable to snapshot config history.")
+
+	for _, entry := range entries {
+		switch entry.Op {
+		case "add":
+			api := entry.API
+			if api == "" {
+				api = "S3v4"
+			}
+			conf.Hosts[entry.Alias] = hostConfigV8{
+				URL:       entry.URL,
+				AccessKey: entry.AccessKey,
+				SecretKey: maybeEncryptSecretKey(ctx, entry.SecretKey),
+				API:       api,
+			}
+		case "remove":
+			delete(conf.Hosts, entry.Alias)
+		case "set":
+			hostCfg := conf.Hosts[entry.Alias]
+			if entry.URL != "" {
+				hostCfg.URL = entry.URL
+			}
+			if entry.AccessKey != "" {
+				hostCfg.AccessKey = entry.AccessKey
+			}
+			if entry.SecretKey != "" {
+				hostCfg.SecretKey = maybeEncryptSecretKey(ctx, entry.SecretKey)
+			}
+			if entry.API != "" {
+				hostCfg.API = entry.API
+			}
+			conf.Hosts[entry.Alias] = hostCfg
+		}
+	}
+
+	err = saveMcConfig(conf)
+	fatalIf(err.Trace(), "Unable to save batch update to config ‘"+mustGetMcConfigPath()+"’.")
+
+	for _, entry := range entries {
+		printMsg(hostMessage{op: entry.Op, Alias: entry.Alias})
+	}
+}
+
+// checkConfigHostSetSyntax - verifies input arguments to 'config host set'.
+func checkConfigHostSetSyntax(ctx *cli.Context) {
+	tailArgs := ctx.Args().Tail()
+
+	if ctx.String("from-file") != "" || (len(tailArgs) == 0 && isStdinPiped()) {
+		return // validated against the full batch in applyHostBatch.
+	}
+
+	if len(tailArgs) < 2 {
+		fatalIf(errInvalidArgument().Trace(tailArgs...),
+			"Incorrect number of arguments for host set command.")
+	}
+
+	if !isValidAlias(tailArgs.Get(0)) {
+		fatalIf(errDummy().Trace(tailArgs.Get(0)), "Invalid alias ‘"+tailArgs.Get(0)+"’.")
+	}
+
+	for _, kv := range tailArgs.Tail() {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			fatalIf(errInvalidArgument().Trace(kv), "Invalid ‘key=value’ pair ‘"+kv+"’.")
+		}
+		switch parts[0] {
+		case "url", "accessKey", "secretKey", "api":
+		default:
+			fatalIf(errInvalidArgument().Trace(kv), "Unrecognized field ‘"+parts[0]+"’.")
+		}
+	}
+}
+
+// setHost - partially updates an existing host entry's fields.
+func setHost(ctx *cli.Context, alias string, fields map[string]string) {
+	conf, err := loadMcConfig()
+	fatalIf(err.Trace(globalMCConfigVersion), "Unable to load config version ‘"+globalMCConfigVersion+"’.")
+
+	hostCfg, ok := conf.Hosts[alias]
+	if !ok {
+		fatalIf(errDummy().Trace(alias), "No such host ‘"+alias+"’.")
+	}
+
+	if url, ok := fields["url"]; ok {
+		if !isValidHostURL(url) {
+			fatalIf(errDummy().Trace(url), "Invalid URL ‘"+url+"’.")
+		}
+		hostCfg.URL = url
+	}
+	if accessKey, ok := fields["accessKey"]; ok {
+		if !isValidAccessKey(accessKey) {
+			fatalIf(errInvalidArgument().Trace(accessKey), "Invalid access key ‘"+accessKey+"’.")
+		}
+		hostCfg.AccessKey = accessKey
+	}
+	if secretKey, ok := fields["secretKey"]; ok {
+		if !isValidSecretKey(secretKey) {
+			fatalIf(errInvalidArgument().Trace(secretKey), "Invalid secret key ‘"+secretKey+"’.")
+		}
+		hostCfg.SecretKey = maybeEncryptSecretKey(ctx, secretKey)
+	}
+	if api, ok := fields["api"]; ok {
+		if !isValidAPI(api) {
+			fatalIf(errInvalidArgument().Trace(api), "Unrecognized API signature. Valid options are ‘[S3v4, S3v2]’.")
+		}
+		hostCfg.API = api
+	}
+
+	fatalIf(snapshotConfigHistory("set", alias, *conf).Trace(alias), "Unable to snapshot config history.")
+
+	conf.Hosts[alias] = hostCfg
+
+	err = saveMcConfig(conf)
+	fatalIf(err.Trace(alias), "Unable to update host ‘"+alias+"’ in config version ‘"+globalMCConfigVersion+"’.")
+
+	printMsg(hostMessage{op: "set", Alias: alias})
+}
@@ -0,0 +1,277 @@
+/*
+ * Minio Client (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/minio/cli"
+	"github.com/minio/minio/pkg/probe"
+)
+
+// encryptedSecretPrefix marks a hostConfigV8.SecretKey value as an
+// Argon2id+AES-256-GCM sealed secret rather than plaintext.
+const encryptedSecretPrefix = "mc-enc-v1$"
+
+const (
+	argon2Time    = 1
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeyLen  = 32
+)
+
+// isEncryptedSecret - true if 's' was produced by encryptHostSecret.
+func isEncryptedSecret(s string) bool {
+	return strings.HasPrefix(s, encryptedSecretPrefix)
+}
+
+// encryptHostSecret - seals 'secretKey' with a key derived from 'passphrase'
+// via Argon2id, using a fresh random salt and nonce. The salt and nonce
+// travel alongside the ciphertext in the returned string so decryption never
+// depends on external state.
+func encryptHostSecret(secretKey string, passphrase []byte) (string, *probe.Error) {
+	if isEncryptedSecret(secretKey) {
+		return secretKey, nil
+	}
+
+	salt := make([]byte, 16)
+	if _, e := rand.Read(salt); e != nil {
+		return "", probe.NewError(e)
+	}
+
+	block, e := aes.NewCipher(argon2.IDKey(passphrase, salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen))
+	if e != nil {
+		return "", probe.NewError(e)
+	}
+	gcm, e := cipher.NewGCM(block)
+	if e != nil {
+		return "", probe.NewError(e)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, e := rand.Read(nonce); e != nil {
+		return "", probe.NewError(e)
+	}
+
+	ciphertext := gcm.Seal(nil, nonce, []byte(secretKey), nil)
+
+	return encryptedSecretPrefix + strings.Join([]string{
+		base64.RawURLEncoding.EncodeToString(salt),
+		base64.RawURLEncoding.EncodeToString(nonce),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+	}, "$"), nil
+}
+
+// decryptHostSecret - reverses encryptHostSecret. Returns an error if
+// 'passphrase' doesn't match or 'encoded' is malformed.
+func decryptHostSecret(encoded string, passphrase []byte) (string, *probe.Error) {
+	if !isEncryptedSecret(encoded) {
+		return encoded, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(encoded, encryptedSecretPrefix), "$")
+	if len(parts) != 3 {
+		return "", probe.NewError(errors.New("malformed encrypted secret key"))
+	}
+
+	salt, e := base64.RawURLEncoding.DecodeString(parts[0])
+	if e != nil {
+		return "", probe.NewError(e)
+	}
+	nonce, e := base64.RawURLEncoding.DecodeString(parts[1])
+	if e != nil {
+		return "", probe.NewError(e)
+	}
+	ciphertext, e := base64.RawURLEncoding.DecodeString(parts[2])
+	if e != nil {
+		return "", probe.NewError(e)
+	}
+
+	block, e := aes.NewCipher(argon2.IDKey(passphrase, salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen))
+	if e != nil {
+		return "", probe.NewError(e)
+	}
+	gcm, e := cipher.NewGCM(block)
+	if e != nil {
+		return "", probe.NewError(e)
+	}
+
+	plaintext, e := gcm.Open(nil, nonce, ciphertext, nil)
+	if e != nil {
+		return "", probe.NewError(errors.New("incorrect passphrase or corrupted secret key"))
+	}
+
+	return string(plaintext), nil
+}
+
+// isOpaqueHostSecret - true if 's' is one of the encoded blobs mc stuffs
+// into hostConfigV8.SecretKey in place of an actual secret key (an
+// STS-backed host's hostConfigV9, or a static-credential profile's session
+// token pairing). These must never be run through encryptHostSecret or
+// decryptHostSecret directly: doing so changes their recognizable prefix
+// and silently breaks the host until the config is unlocked again.
+func isOpaqueHostSecret(s string) bool {
+	return isSTSHostConfig(s) || isSessionHostConfig(s)
+}
+
+// maybeEncryptSecretKey - the one decision point every writer of a
+// hostConfigV8.SecretKey goes through: encrypts 'secretKey' with the config
+// passphrase (MC_CONFIG_PASSPHRASE) unless '--plaintext' was given, no
+// passphrase is set, or 'secretKey' doesn't actually hold a secret (an
+// STS-backed host's encoded hostConfigV9, or a session-credential blob).
+// Single add, set, import and batch add/set all call this instead of each
+// re-deriving the passphrase and calling encryptHostSecret themselves.
+func maybeEncryptSecretKey(ctx *cli.Context, secretKey string) string {
+	if ctx.Bool("plaintext") || isOpaqueHostSecret(secretKey) {
+		return secretKey
+	}
+
+	passphrase := os.Getenv("MC_CONFIG_PASSPHRASE")
+	if passphrase == "" {
+		return secretKey
+	}
+
+	encrypted, err := encryptHostSecret(secretKey, []byte(passphrase))
+	fatalIf(err.Trace(), "Unable to encrypt secret key.")
+	return encrypted
+}
+
+var (
+	cachedConfigPassphraseOnce sync.Once
+	cachedConfigPassphrase     []byte
+)
+
+// mustGetConfigPassphrase - returns the passphrase used to lock/unlock host
+// secrets, from MC_CONFIG_PASSPHRASE if set, otherwise by prompting. The
+// passphrase is only ever asked for once per process, so transparently
+// decrypting many locked hosts (e.g. while listing) doesn't re-prompt.
+func mustGetConfigPassphrase() []byte {
+	cachedConfigPassphraseOnce.Do(func() {
+		if p := os.Getenv("MC_CONFIG_PASSPHRASE"); p != "" {
+			cachedConfigPassphrase = []byte(p)
+			return
+		}
+
+		fmt.Fprint(os.Stderr, "Enter config passphrase: ")
+		passphrase, e := terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		fatalIf(probe.NewError(e), "Unable to read passphrase.")
+
+		cachedConfigPassphrase = passphrase
+	})
+	return cachedConfigPassphrase
+}
+
+// mustDecryptedHostSecretKey - returns hostCfg.SecretKey in the clear,
+// transparently decrypting it with the config passphrase if 'lock' sealed
+// it. This is the decrypt-on-load path every reader of a host's SecretKey
+// (listing it, or handing it to the S3 client construction code that
+// consumes mc's config elsewhere in the tree) must go through instead of
+// reading hostConfigV8.SecretKey directly, so a locked host keeps working
+// transparently rather than authenticating with ciphertext.
+func mustDecryptedHostSecretKey(alias string, hostCfg hostConfigV8) string {
+	if !isEncryptedSecret(hostCfg.SecretKey) {
+		return hostCfg.SecretKey
+	}
+
+	secretKey, err := decryptHostSecret(hostCfg.SecretKey, mustGetConfigPassphrase())
+	fatalIf(err.Trace(alias), "Unable to decrypt secret key for host ‘"+alias+"’.")
+
+	return secretKey
+}
+
+// lockConfigHosts - encrypts every plaintext SecretKey in 'conf' in place.
+// Hosts whose SecretKey doesn't actually hold a secret (isOpaqueHostSecret)
+// are left untouched, so they stay recognizable to their own decoder
+// instead of being sealed into an opaque ciphertext blob.
+func lockConfigHosts(conf *mcConfigV8, passphrase []byte) *probe.Error {
+	for alias, hostCfg := range conf.Hosts {
+		if isOpaqueHostSecret(hostCfg.SecretKey) {
+			continue
+		}
+		enc, err := encryptHostSecret(hostCfg.SecretKey, passphrase)
+		if err != nil {
+			return err.Trace(alias)
+		}
+		hostCfg.SecretKey = enc
+		conf.Hosts[alias] = hostCfg
+	}
+	return nil
+}
+
+// unlockConfigHosts - decrypts every encrypted SecretKey in 'conf' in place.
+// Opaque-blob hosts are skipped for the same reason as in lockConfigHosts.
+func unlockConfigHosts(conf *mcConfigV8, passphrase []byte) *probe.Error {
+	for alias, hostCfg := range conf.Hosts {
+		if isOpaqueHostSecret(hostCfg.SecretKey) {
+			continue
+		}
+		dec, err := decryptHostSecret(hostCfg.SecretKey, passphrase)
+		if err != nil {
+			return err.Trace(alias)
+		}
+		hostCfg.SecretKey = dec
+		conf.Hosts[alias] = hostCfg
+	}
+	return nil
+}
+
+// lockHosts - implements 'mc config host lock'.
+func lockHosts() {
+	conf, err := loadMcConfig()
+	fatalIf(err.Trace(globalMCConfigVersion), "Unable to load config version ‘"+globalMCConfigVersion+"’.")
+
+	passphrase := mustGetConfigPassphrase()
+
+	// Encrypt before snapshotting, so this snapshot holds no plaintext secret
+	// keys: unlike other operations, 'lock' records the config as it is
+	// after the mutation. Snapshots taken before 'lock' are not re-encrypted.
+	fatalIf(lockConfigHosts(conf, passphrase).Trace(), "Unable to encrypt host secret keys.")
+	fatalIf(snapshotConfigHistory("lock", "*", *conf).Trace(), "Unable to snapshot config history.")
+
+	err = saveMcConfig(conf)
+	fatalIf(err.Trace(), "Unable to save encrypted config.")
+
+	printMsg(hostMessage{op: "lock"})
+}
+
+// unlockHosts - implements 'mc config host unlock'.
+func unlockHosts() {
+	conf, err := loadMcConfig()
+	fatalIf(err.Trace(globalMCConfigVersion), "Unable to load config version ‘"+globalMCConfigVersion+"’.")
+
+	passphrase := mustGetConfigPassphrase()
+
+	fatalIf(snapshotConfigHistory("unlock", "*", *conf).Trace(), "Unable to snapshot config history.")
+	fatalIf(unlockConfigHosts(conf, passphrase).Trace(), "Unable to decrypt host secret keys.")
+
+	err = saveMcConfig(conf)
+	fatalIf(err.Trace(), "Unable to save decrypted config.")
+
+	printMsg(hostMessage{op: "unlock"})
+}
@@ -0,0 +1,290 @@
+/*
+ * Minio Client (C) 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-ini/ini"
+	"github.com/minio/cli"
+	"github.com/minio/minio/pkg/probe"
+)
+
+// sessionHostConfigPrefix marks a hostConfigV8.SecretKey value as a
+// JSON-encoded staticSessionCredentials rather than a plain secret key,
+// used for AWS profiles that carry a temporary session token (SSO or
+// assumed-role profiles) alongside their secret key.
+const sessionHostConfigPrefix = "mc-session-v1$"
+
+// staticSessionCredentials pairs a secret key with the session token it must
+// be presented alongside, serialized into hostConfigV8.SecretKey the same
+// way encodeSTSHostConfig does for STS-backed hosts.
+type staticSessionCredentials struct {
+	SecretKey    string `json:"secretKey"`
+	SessionToken string `json:"sessionToken"`
+}
+
+// isSessionHostConfig - true if 's' carries encoded staticSessionCredentials.
+func isSessionHostConfig(s string) bool {
+	return strings.HasPrefix(s, sessionHostConfigPrefix)
+}
+
+// encodeSessionHostConfig - serializes 'cfg' for storage in
+// hostConfigV8.SecretKey.
+func encodeSessionHostConfig(cfg staticSessionCredentials) (string, *probe.Error) {
+	data, e := json.Marshal(cfg)
+	if e != nil {
+		return "", probe.NewError(e)
+	}
+	return sessionHostConfigPrefix + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeSessionHostConfig - reverses encodeSessionHostConfig.
+func decodeSessionHostConfig(s string) (staticSessionCredentials, *probe.Error) {
+	var cfg staticSessionCredentials
+	data, e := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, sessionHostConfigPrefix))
+	if e != nil {
+		return cfg, probe.NewError(e)
+	}
+	if e = json.Unmarshal(data, &cfg); e != nil {
+		return cfg, probe.NewError(e)
+	}
+	return cfg, nil
+}
+
+// awsRegionEndpoints maps well-known AWS regions to their S3 endpoint, used
+// to default the URL of an imported profile when '--endpoint' isn't given.
+var awsRegionEndpoints = map[string]string{
+	"us-east-1":      "https://s3.amazonaws.com",
+	"us-east-2":      "https://s3.us-east-2.amazonaws.com",
+	"us-west-1":      "https://s3-us-west-1.amazonaws.com",
+	"us-west-2":      "https://s3-us-west-2.amazonaws.com",
+	"eu-west-1":      "https://s3-eu-west-1.amazonaws.com",
+	"eu-central-1":   "https://s3-eu-central-1.amazonaws.com",
+	"ap-southeast-1": "https://s3-ap-southeast-1.amazonaws.com",
+	"ap-southeast-2": "https://s3-ap-southeast-2.amazonaws.com",
+	"ap-northeast-1": "https://s3-ap-northeast-1.amazonaws.com",
+	"sa-east-1":      "https://s3-sa-east-1.amazonaws.com",
+}
+
+// awsProfile is a single profile assembled from the AWS credentials/config
+// files and/or the AWS_* environment variables.
+type awsProfile struct {
+	Name         string
+	Region       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// importHostsFromAWS - implements 'mc config host import'.
+func importHostsFromAWS(ctx *cli.Context) {
+	profileFilter := ctx.String("profile")
+	endpoint := ctx.String("endpoint")
+	dryRun := ctx.Bool("dry-run")
+
+	profiles := loadAWSProfiles()
+
+	if profileFilter != "" {
+		filtered := make(map[string]awsProfile)
+		if p, ok := profiles[profileFilter]; ok {
+			filtered[profileFilter] = p
+		}
+		profiles = filtered
+	}
+
+	if len(profiles) == 0 {
+		fatalIf(errDummy().Trace(profileFilter),
+			"No matching AWS profiles found in credentials/config files or environment.")
+	}
+
+	for name, p := range profiles {
+		url := endpoint
+		if url == "" {
+			url = awsRegionEndpoints[p.Region]
+		}
+		if url == "" {
+			url = awsRegionEndpoints["us-east-1"]
+		}
+
+		if !isValidHostURL(url) {
+			fatalIf(errDummy().Trace(url), "Invalid URL ‘"+url+"’ for profile ‘"+name+"’.")
+		}
+		if !isValidAccessKey(p.AccessKey) {
+			fatalIf(errInvalidArgument().Trace(p.AccessKey), "Invalid access key for profile ‘"+name+"’.")
+		}
+		if !isValidSecretKey(p.SecretKey) {
+			fatalIf(errInvalidArgument().Trace(p.SecretKey), "Invalid secret key for profile ‘"+name+"’.")
+		}
+
+		secretKey := p.SecretKey
+		if p.SessionToken != "" {
+			var err *probe.Error
+			secretKey, err = encodeSessionHostConfig(staticSessionCredentials{
+				SecretKey:    p.SecretKey,
+				SessionToken: p.SessionToken,
+			})
+			fatalIf(err.Trace(name), "Unable to encode session credentials for profile ‘"+name+"’.")
+		}
+
+		hostCfg := hostConfigV8{
+			URL:       url,
+			AccessKey: p.AccessKey,
+			SecretKey: secretKey,
+			API:       "S3v4",
+		}
+
+		if dryRun {
+			printMsg(hostMessage{
+				op:        "import-dry-run",
+				Alias:     name,
+				URL:       hostCfg.URL,
+				AccessKey: hostCfg.AccessKey,
+				SecretKey: hostCfg.SecretKey,
+				API:       hostCfg.API,
+			})
+			continue
+		}
+
+		addHost(ctx, name, hostCfg)
+	}
+}
+
+// loadAWSProfiles - merges profiles found in the AWS credentials file, the
+// AWS config file, and the AWS_* environment variables, in that order of
+// increasing precedence (env vars win).
+func loadAWSProfiles() map[string]awsProfile {
+	profiles := make(map[string]awsProfile)
+
+	mergeAWSCredentialsFile(profiles, mustGetAWSCredentialsFile())
+	mergeAWSConfigFile(profiles, mustGetAWSConfigFile())
+	mergeAWSEnv(profiles)
+
+	return profiles
+}
+
+// mustGetAWSCredentialsFile - honors AWS_SHARED_CREDENTIALS_FILE, defaulting
+// to ~/.aws/credentials.
+func mustGetAWSCredentialsFile() string {
+	if f := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); f != "" {
+		return f
+	}
+	homeDir, e := homeDir()
+	fatalIf(probe.NewError(e), "Unable to determine home directory.")
+	return filepath.Join(homeDir, ".aws", "credentials")
+}
+
+// mustGetAWSConfigFile - honors AWS_CONFIG_FILE, defaulting to ~/.aws/config.
+func mustGetAWSConfigFile() string {
+	if f := os.Getenv("AWS_CONFIG_FILE"); f != "" {
+		return f
+	}
+	homeDir, e := homeDir()
+	fatalIf(probe.NewError(e), "Unable to determine home directory.")
+	return filepath.Join(homeDir, ".aws", "config")
+}
+
+// mergeAWSCredentialsFile - reads `[profile]` sections of an INI-formatted
+// AWS credentials file into 'profiles'.
+func mergeAWSCredentialsFile(profiles map[string]awsProfile, path string) {
+	if _, e := os.Stat(path); e != nil {
+		return
+	}
+	cfg, e := ini.Load(path)
+	if e != nil {
+		return
+	}
+	for _, section := range cfg.Sections() {
+		name := section.Name()
+		if name == ini.DefaultSection {
+			name = "default"
+		}
+		p := profiles[name]
+		p.Name = name
+		if k := section.Key("aws_access_key_id"); k.String() != "" {
+			p.AccessKey = k.String()
+		}
+		if k := section.Key("aws_secret_access_key"); k.String() != "" {
+			p.SecretKey = k.String()
+		}
+		if k := section.Key("aws_session_token"); k.String() != "" {
+			p.SessionToken = k.String()
+		}
+		profiles[name] = p
+	}
+}
+
+// mergeAWSConfigFile - reads `[profile name]` (or `[default]`) sections of
+// the AWS CLI config file into 'profiles', filling in the region only.
+func mergeAWSConfigFile(profiles map[string]awsProfile, path string) {
+	if _, e := os.Stat(path); e != nil {
+		return
+	}
+	cfg, e := ini.Load(path)
+	if e != nil {
+		return
+	}
+	for _, section := range cfg.Sections() {
+		name := strings.TrimPrefix(section.Name(), "profile ")
+		if section.Name() == ini.DefaultSection {
+			name = "default"
+		}
+		if name == "" {
+			continue
+		}
+		p := profiles[name]
+		p.Name = name
+		if k := section.Key("region"); k.String() != "" {
+			p.Region = k.String()
+		}
+		profiles[name] = p
+	}
+}
+
+// mergeAWSEnv - overlays AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY/
+// AWS_SESSION_TOKEN onto the profile named by AWS_PROFILE (or "default").
+func mergeAWSEnv(profiles map[string]awsProfile) {
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	sessionToken := os.Getenv("AWS_SESSION_TOKEN")
+
+	if accessKey == "" && secretKey == "" && sessionToken == "" {
+		return
+	}
+
+	name := os.Getenv("AWS_PROFILE")
+	if name == "" {
+		name = "default"
+	}
+
+	p := profiles[name]
+	p.Name = name
+	if accessKey != "" {
+		p.AccessKey = accessKey
+	}
+	if secretKey != "" {
+		p.SecretKey = secretKey
+	}
+	if sessionToken != "" {
+		p.SessionToken = sessionToken
+	}
+	profiles[name] = p
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/minio/cli"
@@ -29,14 +30,58 @@ import (
 )
 
 var (
-	configHostFlags = []cli.Flag{}
+	configHostFlags = []cli.Flag{
+		cli.IntFlag{
+			Name:  "max-history",
+			Value: defaultMaxConfigHistory,
+			Usage: "maximum number of config snapshots to retain in ‘mc config host history’",
+		},
+		cli.StringFlag{
+			Name:  "profile",
+			Usage: "import a single named profile instead of all profiles (with ‘import’)",
+		},
+		cli.StringFlag{
+			Name:  "endpoint",
+			Usage: "override the S3 endpoint derived from a profile's region (with ‘import’)",
+		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "print what would be imported without touching the config file (with ‘import’)",
+		},
+		cli.BoolFlag{
+			Name:  "plaintext",
+			Usage: "skip secret key encryption, even if MC_CONFIG_PASSPHRASE is set (with ‘add’)",
+		},
+		cli.BoolFlag{
+			Name:  "sts",
+			Usage: "add an STS-backed host that exchanges an OIDC token for temporary credentials (with ‘add’)",
+		},
+		cli.StringFlag{
+			Name:  "oidc-token-file",
+			Usage: "path to the OIDC token used for AssumeRoleWithClientGrants (with ‘add --sts’)",
+		},
+		cli.StringFlag{
+			Name:  "role-arn",
+			Usage: "role ARN to assume (with ‘add --sts’)",
+		},
+		cli.StringFlag{
+			Name:  "duration",
+			Value: "1h",
+			Usage: "lifetime of the assumed role's temporary credentials (with ‘add --sts’)",
+		},
+		cli.StringFlag{
+			Name:  "from-file",
+			Usage: "path to a YAML/JSON file describing multiple hosts to add/remove/set in one batch",
+		},
+	}
 )
 
 var configHostCmd = cli.Command{
-	Name:   "host",
-	Usage:  "List, modify and remove hosts in configuration file.",
-	Flags:  append(configHostFlags, globalFlags...),
-	Action: mainConfigHost,
+	Name:        "host",
+	Usage:       "List, modify and remove hosts in configuration file.",
+	Flags:       append(configHostFlags, globalFlags...),
+	Action:      mainConfigHost,
+	Subcommands: []cli.Command{configHistoryCmd},
 	CustomHelpTemplate: `NAME:
    mc config {{.Name}} - {{.Usage}}
 
@@ -45,8 +90,15 @@ USAGE:
 
 OPERATION:
    add ALIAS URL ACCESS-KEY SECRET-KEY [API]
+   add ALIAS URL --sts --oidc-token-file PATH [--role-arn ARN] [--duration DURATION]
+   add|remove|set --from-file hosts.yaml
    remove ALIAS
+   set ALIAS key=value [key=value ...]
    list
+   import
+   lock
+   unlock
+   history list|restore ID|clear
 
 FLAGS:
   {{range .Flags}}{{.}}
@@ -69,6 +121,31 @@ EXAMPLES:
 
    4. Remove "goodisk" config.
       $ mc config {{.Name}} remove goodisk
+
+   5. Import hosts from the AWS CLI's credentials and config files.
+      $ mc config {{.Name}} import
+
+   6. Import a single AWS CLI profile, previewing the result first.
+      $ mc config {{.Name}} import --profile work --dry-run
+
+   7. Encrypt all stored secret keys with a passphrase.
+      $ mc config {{.Name}} lock
+
+   8. Decrypt all stored secret keys back to plaintext.
+      $ mc config {{.Name}} unlock
+
+   9. Add an STS-backed host using OIDC client grants.
+      $ mc config {{.Name}} add myoidc https://s3.amazonaws.com \
+                  --sts --oidc-token-file /var/run/secrets/token --role-arn arn:aws:iam::123456789012:role/mc --duration 1h
+
+   10. Add many hosts in one batch, committed all at once.
+       $ mc config {{.Name}} add --from-file hosts.yaml
+
+   11. Update just the secret key of an existing host in place.
+       $ mc config {{.Name}} set myphotos secretKey=V8f1CwQqAcwo80UEIJEjc5gVQUSSx5ohQ9GSrr12
+
+   12. List, restore or clear recorded config snapshots.
+       $ mc config {{.Name}} history list
 `,
 }
 
@@ -86,7 +163,7 @@ type hostMessage struct {
 // String colorized host message
 func (h hostMessage) String() string {
 	switch h.op {
-	case "list":
+	case "list", "import-dry-run":
 		message := console.Colorize("Alias", fmt.Sprintf("%s: ", h.Alias))
 		message += console.Colorize("URL", fmt.Sprintf("%-30.30s", h.URL))
 		if h.AccessKey != "" || h.SecretKey != "" {
@@ -94,11 +171,22 @@ func (h hostMessage) String() string {
 			message += console.Colorize("SecretKey", fmt.Sprintf("  %-40.40s", h.SecretKey))
 			message += console.Colorize("API", fmt.Sprintf("  %.20s", h.API))
 		}
+		if h.op == "import-dry-run" {
+			message += console.Colorize("DryRun", "  (dry-run, not written)")
+		}
 		return message
 	case "remove":
 		return console.Colorize("HostMessage", "Removed ‘"+h.Alias+"’ successfully.")
 	case "add":
 		return console.Colorize("HostMessage", "Added ‘"+h.Alias+"’ successfully.")
+	case "set":
+		return console.Colorize("HostMessage", "Updated ‘"+h.Alias+"’ successfully.")
+	case "import":
+		return console.Colorize("HostMessage", "Imported ‘"+h.Alias+"’ successfully.")
+	case "lock":
+		return console.Colorize("HostMessage", "Encrypted all host secret keys successfully.")
+	case "unlock":
+		return console.Colorize("HostMessage", "Decrypted all host secret keys successfully.")
 	default:
 		return ""
 	}
@@ -125,7 +213,12 @@ func checkConfigHostSyntax(ctx *cli.Context) {
 		checkConfigHostAddSyntax(ctx)
 	case "remove":
 		checkConfigHostRemoveSyntax(ctx)
+	case "set":
+		checkConfigHostSetSyntax(ctx)
 	case "list":
+	case "import":
+	case "lock":
+	case "unlock":
 	default:
 		cli.ShowCommandHelpAndExit(ctx, "host", 1) // last argument is exit code
 	}
@@ -135,6 +228,16 @@ func checkConfigHostSyntax(ctx *cli.Context) {
 func checkConfigHostAddSyntax(ctx *cli.Context) {
 	tailArgs := ctx.Args().Tail()
 	tailsArgsNr := len(tailArgs)
+
+	if ctx.Bool("sts") {
+		checkConfigHostAddSTSSyntax(ctx, tailArgs)
+		return
+	}
+
+	if ctx.String("from-file") != "" || (tailsArgsNr == 0 && isStdinPiped()) {
+		return // validated against the full batch in applyHostBatch.
+	}
+
 	if tailsArgsNr < 4 || tailsArgsNr > 5 {
 		fatalIf(errInvalidArgument().Trace(ctx.Args().Tail()...),
 			"Incorrect number of arguments for host add command.")
@@ -171,10 +274,46 @@ func checkConfigHostAddSyntax(ctx *cli.Context) {
 	}
 }
 
+// checkConfigHostAddSTSSyntax - verifies input arguments to
+// 'config host add --sts'.
+func checkConfigHostAddSTSSyntax(ctx *cli.Context, tailArgs cli.Args) {
+	if len(tailArgs) != 2 {
+		fatalIf(errInvalidArgument().Trace(tailArgs...),
+			"Incorrect number of arguments for host add --sts command.")
+	}
+
+	alias := tailArgs.Get(0)
+	url := tailArgs.Get(1)
+
+	if !isValidAlias(alias) {
+		fatalIf(errDummy().Trace(alias), "Invalid alias ‘"+alias+"’.")
+	}
+
+	if !isValidHostURL(url) {
+		fatalIf(errDummy().Trace(url), "Invalid URL ‘"+url+"’.")
+	}
+
+	if ctx.String("oidc-token-file") == "" {
+		fatalIf(errInvalidArgument().Trace(),
+			"‘--oidc-token-file’ is required with ‘add --sts’.")
+	}
+
+	if ctx.String("duration") != "" {
+		if _, e := time.ParseDuration(ctx.String("duration")); e != nil {
+			fatalIf(errInvalidArgument().Trace(ctx.String("duration")),
+				"Invalid ‘--duration’ value.")
+		}
+	}
+}
+
 // checkConfigHostRemoveSyntax - verifies input arguments to 'config host remove'.
 func checkConfigHostRemoveSyntax(ctx *cli.Context) {
 	tailArgs := ctx.Args().Tail()
 
+	if ctx.String("from-file") != "" || (len(tailArgs) == 0 && isStdinPiped()) {
+		return // validated against the full batch in applyHostBatch.
+	}
+
 	if len(ctx.Args().Tail()) != 1 {
 		fatalIf(errInvalidArgument().Trace(tailArgs...),
 			"Incorrect number of arguments for remove host command.")
@@ -193,6 +332,10 @@ func mainConfigHost(ctx *cli.Context) error {
 	// check 'config host' cli arguments.
 	checkConfigHostSyntax(ctx)
 
+	if ctx.IsSet("max-history") {
+		maxConfigHistory = ctx.Int("max-history")
+	}
+
 	// Additional command speific theme customization.
 	console.SetColor("HostMessage", color.New(color.FgGreen))
 	console.SetColor("Alias", color.New(color.FgCyan, color.Bold))
@@ -200,6 +343,7 @@ func mainConfigHost(ctx *cli.Context) error {
 	console.SetColor("AccessKey", color.New(color.FgBlue))
 	console.SetColor("SecretKey", color.New(color.FgBlue))
 	console.SetColor("API", color.New(color.FgYellow))
+	console.SetColor("DryRun", color.New(color.FgRed))
 
 	cmd := ctx.Args().First()
 	args := ctx.Args().Tail()
@@ -207,6 +351,14 @@ func mainConfigHost(ctx *cli.Context) error {
 	// Switch case through commands.
 	switch strings.TrimSpace(cmd) {
 	case "add":
+		if ctx.Bool("sts") {
+			addSTSHost(ctx, args.Get(0), args.Get(1)) // Add an STS-backed host.
+			break
+		}
+		if ctx.String("from-file") != "" || (len(args) == 0 && isStdinPiped()) {
+			applyHostBatch(ctx, "add") // Validate and commit a batch of hosts at once.
+			break
+		}
 		alias := args.Get(0)
 		url := args.Get(1)
 		accessKey := args.Get(2)
@@ -221,21 +373,49 @@ func mainConfigHost(ctx *cli.Context) error {
 			SecretKey: secretKey,
 			API:       api,
 		}
-		addHost(alias, hostCfg) // Add a host with specified credentials.
+		addHost(ctx, alias, hostCfg) // Add a host with specified credentials.
 	case "remove":
+		if ctx.String("from-file") != "" || (len(args) == 0 && isStdinPiped()) {
+			applyHostBatch(ctx, "remove") // Validate and commit a batch of removals at once.
+			break
+		}
 		alias := args.Get(0)
 		removeHost(alias) // Remove a host.
+	case "set":
+		if ctx.String("from-file") != "" || (len(args) == 0 && isStdinPiped()) {
+			applyHostBatch(ctx, "set") // Validate and commit a batch of edits at once.
+			break
+		}
+		alias := args.Get(0)
+		fields := make(map[string]string)
+		for _, kv := range args.Tail() {
+			parts := strings.SplitN(kv, "=", 2)
+			fields[parts[0]] = parts[1]
+		}
+		setHost(ctx, alias, fields) // Partially update an existing host's fields.
 	case "list":
 		listHosts() // List all configured hosts.
+	case "import":
+		importHostsFromAWS(ctx) // Import hosts from AWS credentials/config files and env vars.
+	case "lock":
+		lockHosts() // Encrypt all host secret keys with a passphrase.
+	case "unlock":
+		unlockHosts() // Decrypt all host secret keys back to plaintext.
 	}
 	return nil
 }
 
 // addHost - add a host config.
-func addHost(alias string, hostCfgV8 hostConfigV8) {
+func addHost(ctx *cli.Context, alias string, hostCfgV8 hostConfigV8) {
 	mcCfgV8, err := loadMcConfig()
 	fatalIf(err.Trace(globalMCConfigVersion), "Unable to load config ‘"+mustGetMcConfigPath()+"’.")
 
+	// Snapshot the config as it stands before this mutation, so it can be
+	// restored later via 'mc config host history restore'.
+	fatalIf(snapshotConfigHistory("add", alias, *mcCfgV8).Trace(alias), "Unable to snapshot config history.")
+
+	hostCfgV8.SecretKey = maybeEncryptSecretKey(ctx, hostCfgV8.SecretKey)
+
 	// Add new host.
 	mcCfgV8.Hosts[alias] = hostCfgV8
 
@@ -257,6 +437,10 @@ func removeHost(alias string) {
 	conf, err := loadMcConfig()
 	fatalIf(err.Trace(globalMCConfigVersion), "Unable to load config version ‘"+globalMCConfigVersion+"’.")
 
+	// Snapshot the config as it stands before this mutation, so it can be
+	// restored later via 'mc config host history restore'.
+	fatalIf(snapshotConfigHistory("remove", alias, *conf).Trace(alias), "Unable to snapshot config history.")
+
 	// Remove host.
 	delete(conf.Hosts, alias)
 
@@ -287,12 +471,15 @@ func listHosts() {
   
 	var hosts []hostMessage
 	for k, v := range conf.Hosts {
+		// Transparently decrypts locked hosts and, for STS-backed hosts,
+		// exchanges/refreshes the temporary credentials.
+		accessKey, secretKey, _ := mustResolveHostCredentials(k, v)
 		hosts = append(hosts, hostMessage{
 			op:        "list",
 			Alias:     k,
 			URL:       v.URL,
-			AccessKey: v.AccessKey,
-			SecretKey: v.SecretKey,
+			AccessKey: accessKey,
+			SecretKey: secretKey,
 			API:       v.API,
 		})
 	}